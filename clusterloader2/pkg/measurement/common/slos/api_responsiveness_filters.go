@@ -0,0 +1,216 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slos
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// wildcard matches any value of a matcher field.
+const wildcard = "*"
+
+// matcherAction decides what happens to api calls matched by a thresholdOverride.
+type matcherAction string
+
+const (
+	// actionEnforce is the default: the call's latency is checked against a
+	// threshold (the matcher's own, or the built-in default if unset) and a
+	// violation fails the test.
+	actionEnforce matcherAction = "enforce"
+	// actionIgnore drops matching calls from the report and from SLO checks
+	// entirely, the same way the old hard-coded ignoredResources/ignoredVerbs did.
+	actionIgnore matcherAction = "ignore"
+	// actionWarn checks the call's latency like actionEnforce, but only logs a
+	// warning on violation instead of failing the test.
+	actionWarn matcherAction = "warn"
+)
+
+// thresholdOverride is one entry of the "customThresholds" measurement param.
+// Resource/Subresource/Verb/Scope default to the wildcard "*" when empty, so
+// callers only need to set the fields they want to match on.
+type thresholdOverride struct {
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource"`
+	Verb        string `json:"verb"`
+	Scope       string `json:"scope"`
+
+	// Perc50/Perc90/Perc99/Perc999 are threshold durations (e.g. "1s"), parsed
+	// with time.ParseDuration. An empty value falls back to the built-in
+	// default threshold for that quantile.
+	Perc50  string `json:"perc50"`
+	Perc90  string `json:"perc90"`
+	Perc99  string `json:"perc99"`
+	Perc999 string `json:"perc99_9"`
+
+	// Action is one of "ignore", "enforce" (default), or "warn".
+	Action string `json:"action"`
+}
+
+func (m *thresholdOverride) action() matcherAction {
+	if m.Action == "" {
+		return actionEnforce
+	}
+	return matcherAction(m.Action)
+}
+
+// validate reports an error if Action is set to anything other than one of
+// the three known matcherActions, so a typo like "Ignore" fails parsing
+// instead of silently falling through to actionEnforce.
+func (m *thresholdOverride) validate() error {
+	switch m.action() {
+	case actionEnforce, actionIgnore, actionWarn:
+		return nil
+	default:
+		return fmt.Errorf("invalid action %q: must be one of %q, %q, %q", m.Action, actionEnforce, actionIgnore, actionWarn)
+	}
+}
+
+// defaultIgnoredCalls preserves the historical, hard-coded ignore list as the
+// lowest-priority fallback matchers, so existing tests keep behaving the same
+// way unless an equally or more specific "customThresholds" entry overrides
+// them.
+var defaultIgnoredCalls = []thresholdOverride{
+	{Resource: "events", Action: string(actionIgnore)},
+	{Verb: "WATCH", Action: string(actionIgnore)},
+	{Verb: "WATCHLIST", Action: string(actionIgnore)},
+	{Verb: "PROXY", Action: string(actionIgnore)},
+	// TODO(krzysied): figure out why we're getting non-capitalized proxy and fix this.
+	{Verb: "proxy", Action: string(actionIgnore)},
+	{Verb: "CONNECT", Action: string(actionIgnore)},
+}
+
+// thresholdOverridesFromParams parses the optional "customThresholds"
+// measurement param into a list of matchers, in the order given, followed
+// by the built-in ignore defaults as the final fallback tier. The list is not
+// sorted by specificity; resolveOverride picks the most specific match.
+func thresholdOverridesFromParams(params map[string]interface{}) ([]thresholdOverride, error) {
+	raw, ok := params["customThresholds"]
+	if !ok || raw == nil {
+		return defaultIgnoredCalls, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling customThresholds param: %v", err)
+	}
+	var overrides []thresholdOverride
+	if err := json.Unmarshal(data, &overrides); err != nil {
+		return nil, fmt.Errorf("parsing customThresholds param: %v", err)
+	}
+	for i := range overrides {
+		if err := overrides[i].validate(); err != nil {
+			return nil, fmt.Errorf("customThresholds[%d]: %v", i, err)
+		}
+	}
+	return append(overrides, defaultIgnoredCalls...), nil
+}
+
+// matches reports whether the matcher applies to the given call fields, and
+// how specific the match is (the number of non-wildcard fields it pinned
+// down). A matcher whose non-wildcard field disagrees with the call does not
+// match at all.
+func (m *thresholdOverride) matches(resource, subresource, verb, scope string) (score int, ok bool) {
+	for _, pair := range [][2]string{
+		{m.Resource, resource},
+		{m.Subresource, subresource},
+		{m.Verb, verb},
+		{m.Scope, scope},
+	} {
+		matcherField, callField := pair[0], pair[1]
+		if matcherField == "" || matcherField == wildcard {
+			continue
+		}
+		if matcherField != callField {
+			return 0, false
+		}
+		score++
+	}
+	return score, true
+}
+
+// resolveOverride returns the most specific matcher in overrides that applies
+// to the given call fields, or nil if none do. Ties are broken in favor of
+// the earlier entry, which is how user-supplied overrides (listed first) take
+// priority over the built-in ignore defaults (appended last).
+func resolveOverride(resource, subresource, verb, scope string, overrides []thresholdOverride) *thresholdOverride {
+	var best *thresholdOverride
+	bestScore := -1
+	for i := range overrides {
+		score, ok := overrides[i].matches(resource, subresource, verb, scope)
+		if ok && score > bestScore {
+			bestScore = score
+			best = &overrides[i]
+		}
+	}
+	return best
+}
+
+// isIgnoredCall reports whether resource/subresource/verb/scope should be
+// dropped entirely, replacing the historical hard-coded ignoredResources and
+// ignoredVerbs sets.
+func isIgnoredCall(resource, subresource, verb, scope string, overrides []thresholdOverride) bool {
+	match := resolveOverride(resource, subresource, verb, scope, overrides)
+	return match != nil && match.action() == actionIgnore
+}
+
+// thresholdForQuantile returns the threshold duration to enforce for call at
+// the given quantile, preferring an explicit override and falling back to the
+// built-in defaults from getLatencyThreshold/getLatencyThresholdForQuantile.
+func thresholdForQuantile(call *apiCall, quantile float64, override *thresholdOverride) (time.Duration, error) {
+	def := getLatencyThresholdForQuantile(call, quantile)
+	if override == nil {
+		return def, nil
+	}
+	raw := overrideThresholdString(override, quantile)
+	if raw == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid threshold %q for quantile %v: %v", raw, quantile, err)
+	}
+	return d, nil
+}
+
+// enforcedQuantiles are the (quantile, label, accessor) triples that gather
+// and intervalsSummary check against a threshold. Keeping this table in one
+// place means every quantile customThresholds can override (perc50 through
+// perc99_9) is wired into both enforcement paths by construction.
+var enforcedQuantiles = []struct {
+	quantile float64
+	label    string
+	latency  func(*apiCall) time.Duration
+}{
+	{0.5, "perc50", func(call *apiCall) time.Duration { return call.Latency.Perc50 }},
+	{0.9, "perc90", func(call *apiCall) time.Duration { return call.Latency.Perc90 }},
+	{0.99, "perc99", func(call *apiCall) time.Duration { return call.Latency.Perc99 }},
+	{0.999, "perc99.9", func(call *apiCall) time.Duration { return call.Latency.Perc999 }},
+}
+
+func overrideThresholdString(override *thresholdOverride, quantile float64) string {
+	switch {
+	case quantile >= 0.999:
+		return override.Perc999
+	case quantile >= 0.99:
+		return override.Perc99
+	case quantile >= 0.9:
+		return override.Perc90
+	default:
+		return override.Perc50
+	}
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slos
+
+import "testing"
+
+func TestThresholdOverrideMatches(t *testing.T) {
+	tests := []struct {
+		name      string
+		override  thresholdOverride
+		wantScore int
+		wantOK    bool
+	}{
+		{
+			name:      "all wildcard matches anything",
+			override:  thresholdOverride{},
+			wantScore: 0,
+			wantOK:    true,
+		},
+		{
+			name:      "single field pins down one",
+			override:  thresholdOverride{Verb: "LIST"},
+			wantScore: 1,
+			wantOK:    true,
+		},
+		{
+			name:      "disagreeing field does not match",
+			override:  thresholdOverride{Verb: "GET"},
+			wantScore: 0,
+			wantOK:    false,
+		},
+		{
+			name:      "fully specific match",
+			override:  thresholdOverride{Resource: "pods", Subresource: "status", Verb: "LIST", Scope: "namespace"},
+			wantScore: 4,
+			wantOK:    true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			score, ok := tc.override.matches("pods", "status", "LIST", "namespace")
+			if ok != tc.wantOK || score != tc.wantScore {
+				t.Errorf("matches() = (%d, %v), want (%d, %v)", score, ok, tc.wantScore, tc.wantOK)
+			}
+		})
+	}
+}
+
+func TestResolveOverridePrefersMostSpecific(t *testing.T) {
+	overrides := []thresholdOverride{
+		{Verb: "LIST", Action: "warn"},
+		{Resource: "pods", Verb: "LIST", Action: "ignore"},
+	}
+	match := resolveOverride("pods", "", "LIST", "namespace", overrides)
+	if match == nil || match.action() != actionIgnore {
+		t.Fatalf("resolveOverride() = %+v, want the more specific resource+verb matcher", match)
+	}
+}
+
+func TestResolveOverrideTieBreaksToEarlierEntry(t *testing.T) {
+	overrides := []thresholdOverride{
+		{Verb: "LIST", Action: "warn"},
+		{Resource: "pods", Action: "ignore"},
+	}
+	// Both matchers pin down exactly one field (score 1); the first one listed
+	// should win, which is how user-supplied overrides take priority over the
+	// appended defaultIgnoredCalls tier.
+	match := resolveOverride("pods", "", "LIST", "namespace", overrides)
+	if match == nil || match.action() != actionWarn {
+		t.Fatalf("resolveOverride() = %+v, want the earlier-listed matcher to win the tie", match)
+	}
+}
+
+func TestResolveOverrideNoMatch(t *testing.T) {
+	overrides := []thresholdOverride{{Resource: "nodes"}}
+	if match := resolveOverride("pods", "", "LIST", "namespace", overrides); match != nil {
+		t.Fatalf("resolveOverride() = %+v, want nil", match)
+	}
+}
+
+func TestThresholdOverrideValidate(t *testing.T) {
+	tests := []struct {
+		action  string
+		wantErr bool
+	}{
+		{action: "", wantErr: false},
+		{action: "enforce", wantErr: false},
+		{action: "ignore", wantErr: false},
+		{action: "warn", wantErr: false},
+		{action: "Ignore", wantErr: true},
+		{action: "bogus", wantErr: true},
+	}
+	for _, tc := range tests {
+		override := thresholdOverride{Action: tc.action}
+		err := override.validate()
+		if (err != nil) != tc.wantErr {
+			t.Errorf("validate() for Action=%q: err = %v, wantErr = %v", tc.action, err, tc.wantErr)
+		}
+	}
+}
+
+func TestThresholdOverridesFromParamsRejectsInvalidAction(t *testing.T) {
+	params := map[string]interface{}{
+		"customThresholds": []map[string]interface{}{
+			{"verb": "LIST", "action": "Ignore"},
+		},
+	}
+	if _, err := thresholdOverridesFromParams(params); err == nil {
+		t.Errorf("thresholdOverridesFromParams() succeeded, want error for typo'd action %q", "Ignore")
+	}
+}
+
+func TestThresholdForQuantileUsesOverride(t *testing.T) {
+	call := &apiCall{Verb: "GET", Scope: "resource"}
+	override := &thresholdOverride{Perc50: "42ms"}
+
+	got, err := thresholdForQuantile(call, 0.5, override)
+	if err != nil {
+		t.Fatalf("thresholdForQuantile: %v", err)
+	}
+	if want := 42 * 1_000_000; got.Nanoseconds() != int64(want) {
+		t.Errorf("thresholdForQuantile(0.5) = %v, want 42ms", got)
+	}
+}
+
+func TestThresholdForQuantileFallsBackToDefaultWhenOverrideEmpty(t *testing.T) {
+	call := &apiCall{Verb: "GET", Scope: "resource"}
+	override := &thresholdOverride{Perc99: "1s"}
+
+	got, err := thresholdForQuantile(call, 0.5, override)
+	if err != nil {
+		t.Fatalf("thresholdForQuantile: %v", err)
+	}
+	if want := getLatencyThresholdForQuantile(call, 0.5); got != want {
+		t.Errorf("thresholdForQuantile(0.5) = %v, want default %v", got, want)
+	}
+}
+
+func TestThresholdForQuantileInvalidDuration(t *testing.T) {
+	call := &apiCall{Verb: "GET", Scope: "resource"}
+	override := &thresholdOverride{Perc90: "not-a-duration"}
+	if _, err := thresholdForQuantile(call, 0.9, override); err == nil {
+		t.Errorf("thresholdForQuantile() succeeded, want parse error for invalid Perc90")
+	}
+}
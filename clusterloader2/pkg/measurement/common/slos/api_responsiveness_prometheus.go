@@ -21,16 +21,17 @@ TODO(krzysied): This measurement should replace api_responsiveness.go.
 package slos
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/prometheus/common/model"
-	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
-	clientset "k8s.io/client-go/kubernetes"
 	"k8s.io/klog"
 	"k8s.io/perf-tests/clusterloader2/pkg/errors"
 	"k8s.io/perf-tests/clusterloader2/pkg/measurement"
@@ -46,6 +47,27 @@ const (
 	// countQuery %v should be replaced with query window size.
 	countQuery = "sum(increase(apiserver_request_latency_seconds_count[%v])) by (resource, subresource, scope, verb)"
 
+	// nativeHistogramProbeQuery returns a non-empty vector iff the apiserver is
+	// still emitting the classic, bucketed histogram. When it's empty we assume
+	// only native (sparse) histograms are available.
+	nativeHistogramProbeQuery = "count(apiserver_request_duration_seconds_bucket)"
+	// nativeHistogramLatencyQueryFmt computes a single quantile straight from the
+	// raw apiserver_request_duration_seconds histogram, rather than depending on
+	// the apiserver:apiserver_request_latency:histogram_quantile recording rule.
+	// It reads the unsuffixed native histogram series, not the classic _bucket
+	// series, and is only used when nativeHistogramProbeQuery finds no classic
+	// buckets. %v should be replaced with the
+	// quantile (e.g. "0.99") and the query window size, in that order.
+	nativeHistogramLatencyQueryFmt = "histogram_quantile(%v, sum by (resource, subresource, scope, verb, le) (rate(apiserver_request_duration_seconds[%v])))"
+
+	// defaultQuantilesParam is used when the measurement config doesn't set a
+	// "quantiles" param.
+	defaultQuantilesParam = "0.5,0.9,0.99,0.999"
+
+	// defaultViolationTolerance is how many consecutive poll intervals a call
+	// may exceed its latency threshold before gather fails the test over it.
+	defaultViolationTolerance = 2
+
 	latencyWindowSize = 5 * time.Minute
 	queryTimeout      = 5 * time.Minute
 	queryInterval     = 30 * time.Second
@@ -62,6 +84,11 @@ func createAPIResponsivenessPrometheusMeasurement() measurement.Measurement {
 type apiResponsivenessMeasurementPrometheus struct {
 	startTime time.Time
 	apiCalls  map[string]*apiCall
+
+	pollMu        sync.Mutex
+	pollIntervals []intervalSummary
+	stopPolling   chan struct{}
+	pollDone      chan struct{}
 }
 
 func (a *apiResponsivenessMeasurementPrometheus) Execute(config *measurement.MeasurementConfig) ([]measurement.Summary, error) {
@@ -75,11 +102,68 @@ func (a *apiResponsivenessMeasurementPrometheus) Execute(config *measurement.Mea
 	switch action {
 	case "start":
 		a.start()
+		interval, enabled, err := pollIntervalFromParams(config.Params)
+		if err != nil {
+			return summaries, err
+		}
+		if enabled {
+			promClient, quantiles, overrides, err := a.pollingInputs(config)
+			if err != nil {
+				return summaries, err
+			}
+			// Stop any poller left running by a previous "start" that wasn't
+			// followed by "gather", so its stopPolling/pollDone channels aren't
+			// orphaned and its goroutine doesn't leak.
+			a.stopPollingIfRunning()
+			a.startPolling(promClient, interval, quantiles, overrides)
+		}
+	case "poll":
+		promClient, quantiles, overrides, err := a.pollingInputs(config)
+		if err != nil {
+			return summaries, err
+		}
+		if err := a.recordInterval(promClient, quantiles, overrides); err != nil {
+			return summaries, err
+		}
 	case "gather":
-		summary, err := a.gather(config.PrometheusFramework.GetClientSets().GetClient())
+		a.stopPollingIfRunning()
+
+		promClient, err := a.prometheusClient(config)
+		if err != nil {
+			return summaries, err
+		}
+		quantiles, err := quantilesFromParams(config.Params)
+		if err != nil {
+			return summaries, err
+		}
+		overrides, err := thresholdOverridesFromParams(config.Params)
+		if err != nil {
+			return summaries, err
+		}
+		summary, apiCalls, err := a.gather(promClient, quantiles, overrides)
 		if err == nil || errors.IsMetricViolationError(err) {
 			summaries = append(summaries, summary)
 		}
+
+		violationTolerance, tErr := util.GetIntOrDefault(config.Params, "violationTolerance", defaultViolationTolerance)
+		if tErr != nil {
+			return summaries, tErr
+		}
+		intervalsSummary, intervalsErr := a.intervalsSummary(overrides, violationTolerance)
+		summaries = append(summaries, intervalsSummary)
+		if intervalsErr != nil {
+			if err == nil {
+				err = intervalsErr
+			} else {
+				err = fmt.Errorf("%v; %v", err, intervalsErr)
+			}
+		}
+
+		if runID, testName, rErr := runIDAndTestNameFromParams(config.Params); rErr != nil {
+			klog.Warningf("%s: skipping long-term storage export: %v", a, rErr)
+		} else {
+			pushResultsForLongTermStorage(config.Params, apiCalls, runID, testName)
+		}
 		return summaries, err
 	default:
 		return summaries, fmt.Errorf("unknown action %v", action)
@@ -88,6 +172,38 @@ func (a *apiResponsivenessMeasurementPrometheus) Execute(config *measurement.Mea
 	return summaries, nil
 }
 
+// pollingInputs builds the PrometheusClient, quantiles, and threshold
+// overrides shared by the "start"-launched background poller and the
+// explicit "poll" action.
+func (a *apiResponsivenessMeasurementPrometheus) pollingInputs(config *measurement.MeasurementConfig) (*measurementutil.PrometheusClient, []float64, []thresholdOverride, error) {
+	promClient, err := a.prometheusClient(config)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	quantiles, err := quantilesFromParams(config.Params)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	overrides, err := thresholdOverridesFromParams(config.Params)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return promClient, quantiles, overrides, nil
+}
+
+// prometheusClient builds the PrometheusClient to use for this Execute call.
+// It reads the optional "prometheus*" params to decide whether to talk
+// directly to an external Prometheus/Thanos/Cortex endpoint, falling back to
+// proxying through the apiserver to the in-cluster "prometheus-k8s" service
+// when no endpoint is configured.
+func (a *apiResponsivenessMeasurementPrometheus) prometheusClient(config *measurement.MeasurementConfig) (*measurementutil.PrometheusClient, error) {
+	promConfig, err := measurementutil.PrometheusConfigFromParams(config.Params)
+	if err != nil {
+		return nil, err
+	}
+	return measurementutil.NewPrometheusClient(config.PrometheusFramework.GetClientSets().GetClient(), promConfig)
+}
+
 // Dispose cleans up after the measurement.
 func (a *apiResponsivenessMeasurementPrometheus) Dispose() {}
 
@@ -100,8 +216,164 @@ func (a *apiResponsivenessMeasurementPrometheus) start() {
 	a.startTime = time.Now()
 }
 
-func (a *apiResponsivenessMeasurementPrometheus) gather(c clientset.Interface) (measurement.Summary, error) {
-	apiCalls, err := a.gatherApiCalls(c)
+// intervalSummary is one rolling-window snapshot recorded by the poller.
+type intervalSummary struct {
+	Timestamp time.Time `json:"timestamp"`
+	ApiCalls  []apiCall `json:"apiCalls"`
+}
+
+// apiResponsivenessIntervals is the time-series companion to the aggregate
+// apiResponsiveness summary: one entry per poll interval, so a transient SLO
+// blip during a scale-up phase isn't averaged away by a long steady-state tail.
+type apiResponsivenessIntervals struct {
+	Intervals []intervalSummary `json:"intervals"`
+}
+
+// SummaryName returns the name this summary is saved/reported under.
+func (a *apiResponsivenessIntervals) SummaryName() string {
+	return apiResponsivenessPrometheusMeasurementName + "Intervals"
+}
+
+// PrintSummary returns a pretty-printed JSON representation of the summary.
+func (a *apiResponsivenessIntervals) PrintSummary() (string, error) {
+	return util.PrettyPrintJSON(a)
+}
+
+// pollIntervalFromParams parses the optional "interval" measurement param. An
+// empty/unset interval means periodic polling is disabled, preserving the
+// historical start/gather-only behavior.
+func pollIntervalFromParams(params map[string]interface{}) (time.Duration, bool, error) {
+	raw, err := util.GetStringOrDefault(params, "interval", "")
+	if err != nil {
+		return 0, false, err
+	}
+	if raw == "" {
+		return 0, false, nil
+	}
+	interval, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid interval %q: %v", raw, err)
+	}
+	return interval, true, nil
+}
+
+// startPolling launches the background goroutine that, once every interval,
+// samples apiCall latencies over a rolling window of size latencyWindowSize,
+// recording one intervalSummary per tick. It's idempotent-ish in the sense that gather
+// always stops whatever poller start launched before reading pollIntervals.
+func (a *apiResponsivenessMeasurementPrometheus) startPolling(c *measurementutil.PrometheusClient, interval time.Duration, quantiles []float64, overrides []thresholdOverride) {
+	a.stopPolling = make(chan struct{})
+	a.pollDone = make(chan struct{})
+	go func() {
+		defer close(a.pollDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-a.stopPolling:
+				return
+			case <-ticker.C:
+				if err := a.recordInterval(c, quantiles, overrides); err != nil {
+					klog.Warningf("%s: periodic poll failed: %v", a, err)
+				}
+			}
+		}
+	}()
+}
+
+// stopPollingIfRunning stops the background poller started by "start", if
+// any, and waits for its last in-flight recordInterval to finish so gather
+// sees a consistent pollIntervals slice.
+func (a *apiResponsivenessMeasurementPrometheus) stopPollingIfRunning() {
+	if a.stopPolling == nil {
+		return
+	}
+	close(a.stopPolling)
+	<-a.pollDone
+	a.stopPolling = nil
+	a.pollDone = nil
+}
+
+// recordInterval queries the current rolling-window latencies/counts and
+// appends the result to pollIntervals. It's shared by the background poller
+// and the explicit "poll" action.
+func (a *apiResponsivenessMeasurementPrometheus) recordInterval(c *measurementutil.PrometheusClient, quantiles []float64, overrides []thresholdOverride) error {
+	queryTime := time.Now()
+	apiCalls, err := a.gatherApiCallsForWindow(c, quantiles, overrides, latencyWindowSize, queryTime)
+	if err != nil {
+		return err
+	}
+	a.pollMu.Lock()
+	defer a.pollMu.Unlock()
+	a.pollIntervals = append(a.pollIntervals, intervalSummary{Timestamp: queryTime, ApiCalls: apiCalls})
+	return nil
+}
+
+// intervalsSummary builds the apiResponsivenessIntervals summary from the
+// recorded pollIntervals, and fails if any api call's latency at any of the
+// enforcedQuantiles exceeds its threshold for more than violationTolerance
+// consecutive intervals. A violationTolerance <= 0 disables this check. This
+// mirrors how production SLO burn-rate alerts work, and catches regressions
+// that the end-of-test aggregate in gather hides.
+func (a *apiResponsivenessMeasurementPrometheus) intervalsSummary(overrides []thresholdOverride, violationTolerance int) (measurement.Summary, error) {
+	a.pollMu.Lock()
+	intervals := append([]intervalSummary(nil), a.pollIntervals...)
+	a.pollMu.Unlock()
+
+	summary := &apiResponsivenessIntervals{Intervals: intervals}
+	if violationTolerance <= 0 || len(intervals) == 0 {
+		return summary, nil
+	}
+
+	consecutiveViolations := make(map[string]int)
+	var violations []string
+	for _, interval := range intervals {
+		seenThisInterval := make(map[string]bool)
+		for i := range interval.ApiCalls {
+			call := &interval.ApiCalls[i]
+			baseKey := getMetricKey(call.Resource, call.Subresource, call.Verb, call.Scope)
+
+			match := resolveOverride(call.Resource, call.Subresource, call.Verb, call.Scope, overrides)
+			if match != nil && match.action() == actionIgnore {
+				continue
+			}
+			for _, eq := range enforcedQuantiles {
+				key := baseKey + "|" + eq.label
+				seenThisInterval[key] = true
+
+				threshold, err := thresholdForQuantile(call, eq.quantile, match)
+				if err != nil {
+					return summary, err
+				}
+				if eq.latency(call) > threshold {
+					consecutiveViolations[key]++
+					if consecutiveViolations[key] > violationTolerance {
+						violation := fmt.Sprintf("%s %s: %v > %v for %d consecutive intervals", baseKey, eq.label, eq.latency(call), threshold, consecutiveViolations[key])
+						if match != nil && match.action() == actionWarn {
+							klog.Warningf("%s: interval SLO violation demoted to warning by matcher %s: %s", a, matcherLabel(match), violation)
+						} else {
+							violations = append(violations, violation)
+						}
+					}
+				} else {
+					consecutiveViolations[key] = 0
+				}
+			}
+		}
+		for key := range consecutiveViolations {
+			if !seenThisInterval[key] {
+				consecutiveViolations[key] = 0
+			}
+		}
+	}
+	if len(violations) > 0 {
+		return summary, errors.NewMetricViolationError("interval latency burn rate", fmt.Sprintf("sustained high latency: %v", violations))
+	}
+	return summary, nil
+}
+
+func (a *apiResponsivenessMeasurementPrometheus) gather(c *measurementutil.PrometheusClient, quantiles []float64, overrides []thresholdOverride) (measurement.Summary, []apiCall, error) {
+	apiCalls, err := a.gatherApiCalls(c, quantiles, overrides)
 	if err != nil {
 		klog.Errorf("%s: samples gathering error: %v", a, err)
 	}
@@ -111,28 +383,67 @@ func (a *apiResponsivenessMeasurementPrometheus) gather(c clientset.Interface) (
 	var badMetrics []string
 	top := 5
 	for i := range metrics.ApiCalls {
+		call := &metrics.ApiCalls[i]
+		match := resolveOverride(call.Resource, call.Subresource, call.Verb, call.Scope, overrides)
+		action := actionEnforce
+		if match != nil {
+			action = match.action()
+		}
+
 		isBad := false
-		latencyThreshold := getLatencyThreshold(&metrics.ApiCalls[i])
-		if metrics.ApiCalls[i].Latency.Perc99 > latencyThreshold {
-			isBad = true
-			badMetrics = append(badMetrics, fmt.Sprintf("got: %+v; expected perc99 <= %v", metrics.ApiCalls[i], latencyThreshold))
+		var callBadMetrics []string
+		// latencyThreshold is kept around (the perc99 one) purely for the "Top
+		// latency metric" log line below; the actual checks cover every quantile
+		// customThresholds can override, via enforcedQuantiles.
+		var latencyThreshold time.Duration
+		for _, eq := range enforcedQuantiles {
+			threshold, err := thresholdForQuantile(call, eq.quantile, match)
+			if err != nil {
+				return metrics, apiCalls, err
+			}
+			if eq.quantile == 0.99 {
+				latencyThreshold = threshold
+			}
+			if eq.latency(call) > threshold {
+				isBad = true
+				callBadMetrics = append(callBadMetrics, fmt.Sprintf("[%s] got: %+v; expected %s <= %v", matcherLabel(match), call, eq.label, threshold))
+			}
 		}
+
+		if action == actionWarn && isBad {
+			klog.Warningf("%s: SLO violation demoted to warning by matcher %s: %+v", a, matcherLabel(match), call)
+			isBad = false
+		}
+		if isBad {
+			badMetrics = append(badMetrics, callBadMetrics...)
+		}
+
 		if top > 0 || isBad {
 			top--
 			prefix := ""
 			if isBad {
 				prefix = "WARNING "
 			}
-			klog.Infof("%s: %vTop latency metric: %+v; threshold: %v", a, prefix, metrics.ApiCalls[i], latencyThreshold)
+			klog.Infof("%s: %vTop latency metric: %+v; threshold: %v", a, prefix, call, latencyThreshold)
 		}
 	}
 	if len(badMetrics) > 0 {
-		return metrics, errors.NewMetricViolationError("top latency metric", fmt.Sprintf("there should be no high-latency requests, but: %v", badMetrics))
+		return metrics, apiCalls, errors.NewMetricViolationError("top latency metric", fmt.Sprintf("there should be no high-latency requests, but: %v", badMetrics))
+	}
+	return metrics, apiCalls, nil
+}
+
+// matcherLabel describes the matcher responsible for a reported violation, so
+// test authors can tell which "customThresholds" entry (or the built-in
+// default) fired.
+func matcherLabel(m *thresholdOverride) string {
+	if m == nil {
+		return "default"
 	}
-	return metrics, nil
+	return fmt.Sprintf("resource=%s,subresource=%s,verb=%s,scope=%s", m.Resource, m.Subresource, m.Verb, m.Scope)
 }
 
-func (a *apiResponsivenessMeasurementPrometheus) gatherApiCalls(c clientset.Interface) ([]apiCall, error) {
+func (a *apiResponsivenessMeasurementPrometheus) gatherApiCalls(c *measurementutil.PrometheusClient, quantiles []float64, overrides []thresholdOverride) ([]apiCall, error) {
 	measurementEnd := time.Now()
 	measurementDuration := measurementEnd.Sub(a.startTime)
 	// Latency measurement is based on 5m window aggregation,
@@ -141,25 +452,140 @@ func (a *apiResponsivenessMeasurementPrometheus) gatherApiCalls(c clientset.Inte
 	if latencymeasurementDuration < time.Minute {
 		latencymeasurementDuration = time.Minute
 	}
-	timeBoundedLatencyQuery := fmt.Sprintf(latencyQuery, measurementutil.ToPrometheusTime(latencymeasurementDuration))
-	latencySamples, err := gatherSamples(c, timeBoundedLatencyQuery, measurementEnd)
-	if err != nil {
-		return nil, err
+
+	apiCalls := make(map[string]*apiCall)
+	if useNativeHistograms(c, measurementEnd) {
+		klog.V(2).Infof("%s: apiserver appears to export native histograms; using histogram_quantile(%v)", a, quantiles)
+		if err := a.addNativeHistogramLatencies(c, apiCalls, quantiles, overrides, latencymeasurementDuration, measurementEnd); err != nil {
+			return nil, err
+		}
+	} else {
+		timeBoundedLatencyQuery := fmt.Sprintf(latencyQuery, measurementutil.ToPrometheusTime(latencymeasurementDuration))
+		latencySamples, err := gatherSamples(c, timeBoundedLatencyQuery, measurementEnd)
+		if err != nil {
+			return nil, err
+		}
+		if err := addLatencySamples(apiCalls, latencySamples, overrides); err != nil {
+			return nil, err
+		}
 	}
+
 	timeBoundedCountQuery := fmt.Sprintf(countQuery, measurementutil.ToPrometheusTime(measurementDuration))
 	countSamples, err := gatherSamples(c, timeBoundedCountQuery, measurementEnd)
 	if err != nil {
 		return nil, err
 	}
-	return a.convertToApiCalls(latencySamples, countSamples)
+	addCountSamples(apiCalls, countSamples, overrides)
+
+	var result []apiCall
+	for _, call := range apiCalls {
+		result = append(result, *call)
+	}
+	return result, nil
 }
 
-func (a *apiResponsivenessMeasurementPrometheus) convertToApiCalls(latencySamples, countSamples []*model.Sample) ([]apiCall, error) {
+// gatherApiCallsForWindow is the gatherApiCalls counterpart used by the
+// poller: it queries a fixed-size rolling window ending at queryTime instead
+// of deriving the window from a.startTime, since a poll interval has no
+// notion of "skip the first 5 minutes of the test".
+func (a *apiResponsivenessMeasurementPrometheus) gatherApiCallsForWindow(c *measurementutil.PrometheusClient, quantiles []float64, overrides []thresholdOverride, window time.Duration, queryTime time.Time) ([]apiCall, error) {
+	promWindow := measurementutil.ToPrometheusTime(window)
 	apiCalls := make(map[string]*apiCall)
-	ignoredResources := sets.NewString("events")
-	// TODO(krzysied): figure out why we're getting non-capitalized proxy and fix this.
-	ignoredVerbs := sets.NewString("WATCH", "WATCHLIST", "PROXY", "proxy", "CONNECT")
+	if useNativeHistograms(c, queryTime) {
+		if err := a.addNativeHistogramLatencies(c, apiCalls, quantiles, overrides, window, queryTime); err != nil {
+			return nil, err
+		}
+	} else {
+		timeBoundedLatencyQuery := fmt.Sprintf(latencyQuery, promWindow)
+		latencySamples, err := gatherSamples(c, timeBoundedLatencyQuery, queryTime)
+		if err != nil {
+			return nil, err
+		}
+		if err := addLatencySamples(apiCalls, latencySamples, overrides); err != nil {
+			return nil, err
+		}
+	}
+
+	timeBoundedCountQuery := fmt.Sprintf(countQuery, promWindow)
+	countSamples, err := gatherSamples(c, timeBoundedCountQuery, queryTime)
+	if err != nil {
+		return nil, err
+	}
+	addCountSamples(apiCalls, countSamples, overrides)
+
+	var result []apiCall
+	for _, call := range apiCalls {
+		result = append(result, *call)
+	}
+	return result, nil
+}
+
+// useNativeHistograms probes whether the classic, bucketed
+// apiserver_request_duration_seconds histogram is still being exported. If
+// not, we assume the apiserver only emits native (sparse) histograms and
+// switch to computing quantiles directly from the raw series.
+func useNativeHistograms(c *measurementutil.PrometheusClient, queryTime time.Time) bool {
+	samples, err := gatherSamples(c, nativeHistogramProbeQuery, queryTime)
+	if err != nil {
+		klog.Warningf("native histogram probe query failed, assuming classic histograms: %v", err)
+		return false
+	}
+	return len(samples) == 0 || samples[0].Value == 0
+}
+
+// addNativeHistogramLatencies queries histogram_quantile directly against the
+// raw apiserver_request_duration_seconds histogram, once per requested
+// quantile, and records the results into apiCalls.
+func (a *apiResponsivenessMeasurementPrometheus) addNativeHistogramLatencies(c *measurementutil.PrometheusClient, apiCalls map[string]*apiCall, quantiles []float64, overrides []thresholdOverride, window time.Duration, queryTime time.Time) error {
+	for _, quantile := range quantiles {
+		query := fmt.Sprintf(nativeHistogramLatencyQueryFmt, strconv.FormatFloat(quantile, 'f', -1, 64), measurementutil.ToPrometheusTime(window))
+		samples, err := gatherSamples(c, query, queryTime)
+		if err != nil {
+			return fmt.Errorf("querying p%v native histogram latency: %v", quantile*100, err)
+		}
+		for _, sample := range samples {
+			resource := string(sample.Metric["resource"])
+			subresource := string(sample.Metric["subresource"])
+			verb := string(sample.Metric["verb"])
+			scope := string(sample.Metric["scope"])
+			if isIgnoredCall(resource, subresource, verb, scope, overrides) {
+				continue
+			}
+			latency := time.Duration(float64(sample.Value) * float64(time.Second))
+			addLatency(apiCalls, resource, subresource, verb, scope, quantile, latency)
+		}
+	}
+	return nil
+}
+
+// canonicalQuantiles are the only quantiles apiCall.Latency (a
+// measurementutil.LatencyMetric) has fields for; see LatencyMetric.SetQuantile.
+var canonicalQuantiles = map[float64]bool{0.5: true, 0.9: true, 0.99: true, 0.999: true}
 
+// quantilesFromParams parses the optional "quantiles" measurement param, a
+// comma-separated list of the four quantiles LatencyMetric supports:
+// "0.5,0.9,0.99,0.999". Any other value is rejected, since
+// addLatencySamples/addNativeHistogramLatencies have no field to record it in.
+func quantilesFromParams(params map[string]interface{}) ([]float64, error) {
+	raw, err := util.GetStringOrDefault(params, "quantiles", defaultQuantilesParam)
+	if err != nil {
+		return nil, err
+	}
+	var quantiles []float64
+	for _, s := range strings.Split(raw, ",") {
+		q, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quantile %q in %q: %v", s, raw, err)
+		}
+		if !canonicalQuantiles[q] {
+			return nil, fmt.Errorf("unsupported quantile %v in %q: only 0.5, 0.9, 0.99, 0.999 are supported", q, raw)
+		}
+		quantiles = append(quantiles, q)
+	}
+	return quantiles, nil
+}
+
+func addLatencySamples(apiCalls map[string]*apiCall, latencySamples []*model.Sample, overrides []thresholdOverride) error {
 	for _, sample := range latencySamples {
 		resource := string(sample.Metric["resource"])
 		subresource := string(sample.Metric["subresource"])
@@ -167,34 +593,31 @@ func (a *apiResponsivenessMeasurementPrometheus) convertToApiCalls(latencySample
 		scope := string(sample.Metric["scope"])
 		quantile, err := strconv.ParseFloat(string(sample.Metric["quantile"]), 64)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		if ignoredResources.Has(resource) || ignoredVerbs.Has(verb) {
+		if isIgnoredCall(resource, subresource, verb, scope, overrides) {
 			continue
 		}
 
 		latency := time.Duration(float64(sample.Value) * float64(time.Second))
 		addLatency(apiCalls, resource, subresource, verb, scope, quantile, latency)
 	}
+	return nil
+}
 
+func addCountSamples(apiCalls map[string]*apiCall, countSamples []*model.Sample, overrides []thresholdOverride) {
 	for _, sample := range countSamples {
 		resource := string(sample.Metric["resource"])
 		subresource := string(sample.Metric["subresource"])
 		verb := string(sample.Metric["verb"])
 		scope := string(sample.Metric["scope"])
-		if ignoredResources.Has(resource) || ignoredVerbs.Has(verb) {
+		if isIgnoredCall(resource, subresource, verb, scope, overrides) {
 			continue
 		}
 
 		count := int(math.Round(float64(sample.Value)))
 		addCount(apiCalls, resource, subresource, verb, scope, count)
 	}
-
-	var result []apiCall
-	for _, call := range apiCalls {
-		result = append(result, *call)
-	}
-	return result, nil
 }
 
 func getApiCall(apiCalls map[string]*apiCall, resource, subresource, verb, scope string) *apiCall {
@@ -225,22 +648,15 @@ func addCount(apiCalls map[string]*apiCall, resource, subresource, verb, scope s
 	call.Count = count
 }
 
-func gatherSamples(c clientset.Interface, query string, queryTime time.Time) ([]*model.Sample, error) {
+func gatherSamples(c *measurementutil.PrometheusClient, query string, queryTime time.Time) ([]*model.Sample, error) {
 	if queryTime.IsZero() {
 		return nil, fmt.Errorf("query time can't be zero")
 	}
 
-	var body []byte
+	var value model.Value
 	var queryErr error
-	params := map[string]string{
-		"query": query,
-		"time":  queryTime.Format(time.RFC3339),
-	}
 	if err := wait.PollImmediate(queryInterval, queryTimeout, func() (bool, error) {
-		body, queryErr = c.CoreV1().
-			Services("monitoring").
-			ProxyGet("http", "prometheus-k8s", "9090", "api/v1/query", params).
-			DoRaw()
+		value, queryErr = c.Query(context.Background(), query, queryTime)
 		if queryErr != nil {
 			return false, nil
 		}
@@ -252,13 +668,13 @@ func gatherSamples(c clientset.Interface, query string, queryTime time.Time) ([]
 		return nil, fmt.Errorf("query error: %v", err)
 	}
 
-	samples, err := measurementutil.ExtractMetricSamples2(body)
-	if err != nil {
-		return nil, fmt.Errorf("exctracting error: %v", err)
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("expected query %q to return a vector, got %T", query, value)
 	}
 
 	var resultSamples []*model.Sample
-	for _, sample := range samples {
+	for _, sample := range vector {
 		if !math.IsNaN(float64(sample.Value)) {
 			resultSamples = append(resultSamples, sample)
 		}
@@ -282,3 +698,14 @@ func getLatencyThreshold(call *apiCall) time.Duration {
 	}
 	return latencyThreshold
 }
+
+// getLatencyThresholdForQuantile extends getLatencyThreshold to quantiles
+// beyond p99. Tail quantiles are allowed a looser bound, since a handful of
+// slow outliers are expected even on a healthy cluster.
+func getLatencyThresholdForQuantile(call *apiCall, quantile float64) time.Duration {
+	threshold := getLatencyThreshold(call)
+	if quantile > 0.99 {
+		threshold *= 3
+	}
+	return threshold
+}
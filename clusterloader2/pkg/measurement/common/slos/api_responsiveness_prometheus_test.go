@@ -0,0 +1,147 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slos
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/perf-tests/clusterloader2/pkg/errors"
+)
+
+func callWithPerc99(resource, verb, scope string, perc99 time.Duration) apiCall {
+	call := apiCall{Resource: resource, Verb: verb, Scope: scope}
+	call.Latency.SetQuantile(0.99, perc99)
+	return call
+}
+
+func TestIntervalsSummaryFailsAfterSustainedViolation(t *testing.T) {
+	a := &apiResponsivenessMeasurementPrometheus{}
+	call := apiCall{Verb: "GET", Scope: "resource"}
+	over := getLatencyThreshold(&call) * 2
+
+	a.pollIntervals = []intervalSummary{
+		{Timestamp: time.Now(), ApiCalls: []apiCall{callWithPerc99("pods", "GET", "resource", over)}},
+		{Timestamp: time.Now(), ApiCalls: []apiCall{callWithPerc99("pods", "GET", "resource", over)}},
+		{Timestamp: time.Now(), ApiCalls: []apiCall{callWithPerc99("pods", "GET", "resource", over)}},
+	}
+
+	_, err := a.intervalsSummary(nil, 2)
+	if err == nil || !errors.IsMetricViolationError(err) {
+		t.Fatalf("intervalsSummary() err = %v, want a metric violation error after 3 consecutive violating intervals with tolerance 2", err)
+	}
+}
+
+func TestIntervalsSummaryToleratesTransientViolation(t *testing.T) {
+	a := &apiResponsivenessMeasurementPrometheus{}
+	call := apiCall{Verb: "GET", Scope: "resource"}
+	under := getLatencyThreshold(&call) / 2
+	over := getLatencyThreshold(&call) * 2
+
+	// Violates once, then recovers, then violates once more: never 2
+	// consecutive violations, so this should not fail with tolerance 1.
+	a.pollIntervals = []intervalSummary{
+		{Timestamp: time.Now(), ApiCalls: []apiCall{callWithPerc99("pods", "GET", "resource", over)}},
+		{Timestamp: time.Now(), ApiCalls: []apiCall{callWithPerc99("pods", "GET", "resource", under)}},
+		{Timestamp: time.Now(), ApiCalls: []apiCall{callWithPerc99("pods", "GET", "resource", over)}},
+	}
+
+	if _, err := a.intervalsSummary(nil, 1); err != nil {
+		t.Fatalf("intervalsSummary() err = %v, want nil for non-sustained violations", err)
+	}
+}
+
+func TestIntervalsSummarySkipsIgnoredCalls(t *testing.T) {
+	a := &apiResponsivenessMeasurementPrometheus{}
+	call := apiCall{Verb: "WATCH", Scope: "resource"}
+	over := getLatencyThreshold(&call) * 10
+
+	a.pollIntervals = []intervalSummary{
+		{Timestamp: time.Now(), ApiCalls: []apiCall{callWithPerc99("pods", "WATCH", "resource", over)}},
+		{Timestamp: time.Now(), ApiCalls: []apiCall{callWithPerc99("pods", "WATCH", "resource", over)}},
+		{Timestamp: time.Now(), ApiCalls: []apiCall{callWithPerc99("pods", "WATCH", "resource", over)}},
+	}
+
+	if _, err := a.intervalsSummary(defaultIgnoredCalls, 1); err != nil {
+		t.Fatalf("intervalsSummary() err = %v, want nil: WATCH calls are ignored by default", err)
+	}
+}
+
+func TestIntervalsSummaryZeroToleranceDisablesCheck(t *testing.T) {
+	a := &apiResponsivenessMeasurementPrometheus{}
+	call := apiCall{Verb: "GET", Scope: "resource"}
+	over := getLatencyThreshold(&call) * 2
+	a.pollIntervals = []intervalSummary{
+		{Timestamp: time.Now(), ApiCalls: []apiCall{callWithPerc99("pods", "GET", "resource", over)}},
+	}
+	if _, err := a.intervalsSummary(nil, 0); err != nil {
+		t.Fatalf("intervalsSummary() err = %v, want nil when violationTolerance <= 0", err)
+	}
+}
+
+func TestStopPollingIfRunningStopsBackgroundGoroutine(t *testing.T) {
+	a := &apiResponsivenessMeasurementPrometheus{}
+	a.stopPolling = make(chan struct{})
+	a.pollDone = make(chan struct{})
+	stopPolling, pollDone := a.stopPolling, a.pollDone
+	go func() {
+		<-stopPolling
+		close(pollDone)
+	}()
+
+	a.stopPollingIfRunning()
+
+	select {
+	case <-pollDone:
+	default:
+		t.Fatalf("stopPollingIfRunning did not wait for the poller goroutine to finish")
+	}
+	if a.stopPolling != nil || a.pollDone != nil {
+		t.Errorf("stopPollingIfRunning left stale channels: stopPolling=%v pollDone=%v", a.stopPolling, a.pollDone)
+	}
+}
+
+func TestStopPollingIfRunningNoopWhenNotRunning(t *testing.T) {
+	a := &apiResponsivenessMeasurementPrometheus{}
+	a.stopPollingIfRunning() // must not panic or block
+}
+
+// TestRestartingPollingWithoutGatherDoesNotLeakPreviousPoller exercises the
+// "start" handler's fix: if a previous poller is still running (because
+// "gather" never stopped it), starting a new one must signal the old one
+// first instead of overwriting stopPolling/pollDone out from under it.
+func TestRestartingPollingWithoutGatherDoesNotLeakPreviousPoller(t *testing.T) {
+	a := &apiResponsivenessMeasurementPrometheus{}
+
+	a.stopPolling = make(chan struct{})
+	a.pollDone = make(chan struct{})
+	firstStop, firstDone := a.stopPolling, a.pollDone
+	go func() {
+		<-firstStop
+		close(firstDone)
+	}()
+
+	a.stopPollingIfRunning()
+	a.stopPolling = make(chan struct{})
+	a.pollDone = make(chan struct{})
+
+	select {
+	case <-firstDone:
+	default:
+		t.Fatalf("previous poller goroutine was never signaled to stop; it would leak")
+	}
+}
@@ -0,0 +1,131 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slos
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"k8s.io/klog"
+	measurementutil "k8s.io/perf-tests/clusterloader2/pkg/measurement/util"
+	"k8s.io/perf-tests/clusterloader2/pkg/util"
+)
+
+// pushResultsForLongTermStorage translates apiCalls into labeled time series,
+// one latency series per (resource,subresource,verb,scope,quantile) tuple plus
+// one count series per call (see apiCallsToTimeSeries), and ships them to
+// whichever result sinks are configured via the "results*" measurement
+// params. It never fails the measurement: a sink push failure is logged and
+// swallowed, since long-term storage export is a best-effort side channel,
+// not part of the test's pass/fail signal.
+func pushResultsForLongTermStorage(params map[string]interface{}, apiCalls []apiCall, runID, testName string) {
+	series := apiCallsToTimeSeries(apiCalls, runID, testName)
+	if len(series) == 0 {
+		return
+	}
+
+	ctx := context.Background()
+	if remoteWriteConfig, err := measurementutil.PrometheusConfigFromParamsWithPrefix(params, "resultsRemoteWrite"); err != nil {
+		klog.Warningf("invalid resultsRemoteWrite config, skipping: %v", err)
+	} else if remoteWriteConfig.Endpoint != "" {
+		sink, err := measurementutil.NewRemoteWriteSink(remoteWriteConfig)
+		if err != nil {
+			klog.Warningf("creating remote_write results sink: %v", err)
+		} else if err := sink.Push(ctx, series); err != nil {
+			klog.Warningf("pushing results to remote_write: %v", err)
+		}
+	}
+
+	if otlpConfig, err := measurementutil.PrometheusConfigFromParamsWithPrefix(params, "resultsOTLP"); err != nil {
+		klog.Warningf("invalid resultsOTLP config, skipping: %v", err)
+	} else if otlpConfig.Endpoint != "" {
+		sink, err := measurementutil.NewOTLPSink(ctx, otlpConfig)
+		if err != nil {
+			klog.Warningf("creating OTLP results sink: %v", err)
+		} else {
+			defer sink.Shutdown(ctx)
+			if err := sink.Push(ctx, series); err != nil {
+				klog.Warningf("pushing results to OTLP: %v", err)
+			}
+		}
+	}
+}
+
+// apiCallsToTimeSeries builds one labeled latency sample per
+// (resource,subresource,verb,scope,quantile) tuple with a non-zero latency,
+// plus one request-count sample per call, so trend-analysis backends can
+// track each call's latency percentiles across runs.
+func apiCallsToTimeSeries(apiCalls []apiCall, runID, testName string) []measurementutil.TimeSeries {
+	now := time.Now()
+	var series []measurementutil.TimeSeries
+	for _, call := range apiCalls {
+		for quantile, latency := range map[string]time.Duration{
+			"0.5":   call.Latency.Perc50,
+			"0.9":   call.Latency.Perc90,
+			"0.99":  call.Latency.Perc99,
+			"0.999": call.Latency.Perc999,
+		} {
+			if latency == 0 {
+				continue
+			}
+			series = append(series, measurementutil.TimeSeries{
+				Labels: map[string]string{
+					"__name__":    "clusterloader2_api_call_latency_seconds",
+					"resource":    call.Resource,
+					"subresource": call.Subresource,
+					"verb":        call.Verb,
+					"scope":       call.Scope,
+					"quantile":    quantile,
+					"run_id":      runID,
+					"test_name":   testName,
+				},
+				Value:     latency.Seconds(),
+				Timestamp: now,
+			})
+		}
+		series = append(series, measurementutil.TimeSeries{
+			Labels: map[string]string{
+				"__name__":    "clusterloader2_api_call_count",
+				"resource":    call.Resource,
+				"subresource": call.Subresource,
+				"verb":        call.Verb,
+				"scope":       call.Scope,
+				"run_id":      runID,
+				"test_name":   testName,
+			},
+			Value:     float64(call.Count),
+			Timestamp: now,
+		})
+	}
+	return series
+}
+
+// runIDAndTestNameFromParams reads the "runID" and "testName" labels applied
+// to every exported time series, defaulting testName to the measurement name
+// when the framework config doesn't carry a more specific one.
+func runIDAndTestNameFromParams(params map[string]interface{}) (string, string, error) {
+	runID, err := util.GetStringOrDefault(params, "runID", strconv.FormatInt(time.Now().Unix(), 10))
+	if err != nil {
+		return "", "", err
+	}
+	testName, err := util.GetStringOrDefault(params, "testName", apiResponsivenessPrometheusMeasurementName)
+	if err != nil {
+		return "", "", err
+	}
+	return runID, testName, nil
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slos
+
+import "testing"
+
+func TestQuantilesFromParamsDefault(t *testing.T) {
+	quantiles, err := quantilesFromParams(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("quantilesFromParams: %v", err)
+	}
+	want := []float64{0.5, 0.9, 0.99, 0.999}
+	if len(quantiles) != len(want) {
+		t.Fatalf("quantilesFromParams() = %v, want %v", quantiles, want)
+	}
+	for i := range want {
+		if quantiles[i] != want[i] {
+			t.Errorf("quantiles[%d] = %v, want %v", i, quantiles[i], want[i])
+		}
+	}
+}
+
+func TestQuantilesFromParamsCustomSubset(t *testing.T) {
+	quantiles, err := quantilesFromParams(map[string]interface{}{"quantiles": "0.9,0.99"})
+	if err != nil {
+		t.Fatalf("quantilesFromParams: %v", err)
+	}
+	want := []float64{0.9, 0.99}
+	if len(quantiles) != len(want) || quantiles[0] != want[0] || quantiles[1] != want[1] {
+		t.Fatalf("quantilesFromParams() = %v, want %v", quantiles, want)
+	}
+}
+
+func TestQuantilesFromParamsRejectsNonCanonical(t *testing.T) {
+	// 0.75 and 0.95 have no field in measurementutil.LatencyMetric to land in,
+	// so they must be rejected rather than silently dropped or misfiled.
+	for _, raw := range []string{"0.5,0.75", "0.95"} {
+		if _, err := quantilesFromParams(map[string]interface{}{"quantiles": raw}); err == nil {
+			t.Errorf("quantilesFromParams(%q) succeeded, want error for unsupported quantile", raw)
+		}
+	}
+}
+
+func TestQuantilesFromParamsRejectsGarbage(t *testing.T) {
+	if _, err := quantilesFromParams(map[string]interface{}{"quantiles": "not-a-number"}); err == nil {
+		t.Errorf("quantilesFromParams(garbage) succeeded, want parse error")
+	}
+}
+
+func TestGetLatencyThresholdForQuantileLoosensTailQuantiles(t *testing.T) {
+	call := &apiCall{Verb: "GET", Scope: "resource"}
+	p99 := getLatencyThresholdForQuantile(call, 0.99)
+	p999 := getLatencyThresholdForQuantile(call, 0.999)
+	if p999 <= p99 {
+		t.Errorf("getLatencyThresholdForQuantile(0.999) = %v, want looser bound than p99 = %v", p999, p99)
+	}
+}
@@ -0,0 +1,216 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Upstream k8s removed the in-tree scheduler/metrics latency collector; this
+measurement is the clusterloader2-side, Prometheus-based replacement, modeled
+on apiResponsivenessMeasurementPrometheus.
+*/
+
+package slos
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/klog"
+	"k8s.io/perf-tests/clusterloader2/pkg/errors"
+	"k8s.io/perf-tests/clusterloader2/pkg/measurement"
+	measurementutil "k8s.io/perf-tests/clusterloader2/pkg/measurement/util"
+	"k8s.io/perf-tests/clusterloader2/pkg/util"
+)
+
+const (
+	schedulerLatencyPrometheusMeasurementName = "SchedulerLatencyPrometheus"
+
+	// schedulerLatencyQueryFmt computes a single quantile for a scheduler phase
+	// histogram. %v should be replaced with the quantile, the metric name, and
+	// the query window size, in that order.
+	schedulerLatencyQueryFmt = "histogram_quantile(%v, sum by (le) (rate(%v[%v])))"
+
+	// defaultSchedulerPhaseThreshold is the default perc99 SLO applied to every
+	// phase, overridable per-phase via the "phaseThresholds" param. Other
+	// quantiles are reported but not checked.
+	defaultSchedulerPhaseThreshold = 1 * time.Second
+)
+
+// schedulerPhaseMetrics maps the scheduler phase name reported in the summary
+// to the Prometheus histogram metric backing it.
+var schedulerPhaseMetrics = map[string]string{
+	"e2e_scheduling":       "scheduler_e2e_scheduling_duration_seconds",
+	"scheduling_algorithm": "scheduler_scheduling_algorithm_duration_seconds",
+	"binding":              "scheduler_binding_duration_seconds",
+	"pod_scheduling":       "scheduler_pod_scheduling_duration_seconds",
+}
+
+func init() {
+	measurement.Register(schedulerLatencyPrometheusMeasurementName, createSchedulerLatencyPrometheusMeasurement)
+}
+
+func createSchedulerLatencyPrometheusMeasurement() measurement.Measurement {
+	return &schedulerLatencyMeasurementPrometheus{}
+}
+
+type schedulerLatencyMeasurementPrometheus struct {
+	startTime time.Time
+}
+
+func (s *schedulerLatencyMeasurementPrometheus) Execute(config *measurement.MeasurementConfig) ([]measurement.Summary, error) {
+	var summaries []measurement.Summary
+
+	action, err := util.GetString(config.Params, "action")
+	if err != nil {
+		return summaries, err
+	}
+
+	switch action {
+	case "start":
+		s.start()
+	case "gather":
+		promConfig, err := measurementutil.PrometheusConfigFromParams(config.Params)
+		if err != nil {
+			return summaries, err
+		}
+		promClient, err := measurementutil.NewPrometheusClient(config.PrometheusFramework.GetClientSets().GetClient(), promConfig)
+		if err != nil {
+			return summaries, err
+		}
+		quantiles, err := quantilesFromParams(config.Params)
+		if err != nil {
+			return summaries, err
+		}
+		phaseThresholds, err := phaseThresholdsFromParams(config.Params)
+		if err != nil {
+			return summaries, err
+		}
+		summary, err := s.gather(promClient, quantiles, phaseThresholds)
+		if err == nil || errors.IsMetricViolationError(err) {
+			summaries = append(summaries, summary)
+		}
+		return summaries, err
+	default:
+		return summaries, fmt.Errorf("unknown action %v", action)
+	}
+
+	return summaries, nil
+}
+
+// Dispose cleans up after the measurement.
+func (s *schedulerLatencyMeasurementPrometheus) Dispose() {}
+
+// String returns string representation of this measurement.
+func (*schedulerLatencyMeasurementPrometheus) String() string {
+	return schedulerLatencyPrometheusMeasurementName
+}
+
+func (s *schedulerLatencyMeasurementPrometheus) start() {
+	s.startTime = time.Now()
+}
+
+func (s *schedulerLatencyMeasurementPrometheus) gather(c *measurementutil.PrometheusClient, quantiles []float64, phaseThresholds map[string]time.Duration) (measurement.Summary, error) {
+	measurementEnd := time.Now()
+	window := measurementutil.ToPrometheusTime(measurementEnd.Sub(s.startTime))
+
+	phases := make([]schedulingPhase, 0, len(schedulerPhaseMetrics))
+	var badMetrics []string
+	for name, metric := range schedulerPhaseMetrics {
+		phase := schedulingPhase{Name: name}
+		for _, quantile := range quantiles {
+			query := fmt.Sprintf(schedulerLatencyQueryFmt, formatQuantile(quantile), metric, window)
+			samples, err := gatherSamples(c, query, measurementEnd)
+			if err != nil {
+				klog.Errorf("%s: gathering %s failed: %v", s, metric, err)
+				continue
+			}
+			for _, sample := range samples {
+				latency := time.Duration(float64(sample.Value) * float64(time.Second))
+				phase.Latency.SetQuantile(quantile, latency)
+			}
+		}
+		threshold := defaultSchedulerPhaseThreshold
+		if t, ok := phaseThresholds[name]; ok {
+			threshold = t
+		}
+		if phase.Latency.Perc99 > threshold {
+			badMetrics = append(badMetrics, fmt.Sprintf("got: %+v; expected perc99 <= %v", phase, threshold))
+		}
+		klog.Infof("%s: phase %q latency: %+v; threshold: %v", s, name, phase, threshold)
+		phases = append(phases, phase)
+	}
+	sort.Slice(phases, func(i, j int) bool { return phases[i].Name < phases[j].Name })
+
+	summary := &schedulerLatency{Phases: phases}
+	if len(badMetrics) > 0 {
+		return summary, errors.NewMetricViolationError("scheduler latency", fmt.Sprintf("there should be no high-latency scheduling phases, but: %v", badMetrics))
+	}
+	return summary, nil
+}
+
+// phaseThresholdsFromParams parses the optional "phaseThresholds" measurement
+// param, a map from phase name (see schedulerPhaseMetrics) to a perc99
+// threshold duration string such as "1s".
+func phaseThresholdsFromParams(params map[string]interface{}) (map[string]time.Duration, error) {
+	raw, ok := params["phaseThresholds"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	rawMap, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("phaseThresholds must be a map from phase name to threshold duration, got %T", raw)
+	}
+	thresholds := make(map[string]time.Duration, len(rawMap))
+	for phase, v := range rawMap {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("phaseThresholds[%q] must be a duration string, got %T", phase, v)
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("phaseThresholds[%q]: %v", phase, err)
+		}
+		thresholds[phase] = d
+	}
+	return thresholds, nil
+}
+
+func formatQuantile(q float64) string {
+	return fmt.Sprintf("%v", q)
+}
+
+// schedulingPhase is the per-phase latency entry reported by
+// SchedulerLatencyPrometheus, mirroring the apiCall/apiResponsiveness Summary
+// JSON shape so downstream tooling can consume both uniformly.
+type schedulingPhase struct {
+	Name    string                        `json:"name"`
+	Latency measurementutil.LatencyMetric `json:"latency"`
+}
+
+// schedulerLatency is the measurement.Summary returned by
+// SchedulerLatencyPrometheus.
+type schedulerLatency struct {
+	Phases []schedulingPhase `json:"phases"`
+}
+
+// SummaryName returns the name this summary is saved/reported under.
+func (s *schedulerLatency) SummaryName() string {
+	return schedulerLatencyPrometheusMeasurementName
+}
+
+// PrintSummary returns a pretty-printed JSON representation of the summary.
+func (s *schedulerLatency) PrintSummary() (string, error) {
+	return util.PrettyPrintJSON(s)
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package slos
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPhaseThresholdsFromParamsUnset(t *testing.T) {
+	thresholds, err := phaseThresholdsFromParams(map[string]interface{}{})
+	if err != nil {
+		t.Fatalf("phaseThresholdsFromParams: %v", err)
+	}
+	if thresholds != nil {
+		t.Errorf("phaseThresholdsFromParams() = %v, want nil when unset", thresholds)
+	}
+}
+
+func TestPhaseThresholdsFromParams(t *testing.T) {
+	params := map[string]interface{}{
+		"phaseThresholds": map[string]interface{}{
+			"binding":        "500ms",
+			"e2e_scheduling": "2s",
+		},
+	}
+	thresholds, err := phaseThresholdsFromParams(params)
+	if err != nil {
+		t.Fatalf("phaseThresholdsFromParams: %v", err)
+	}
+	if got, want := thresholds["binding"], 500*time.Millisecond; got != want {
+		t.Errorf("thresholds[binding] = %v, want %v", got, want)
+	}
+	if got, want := thresholds["e2e_scheduling"], 2*time.Second; got != want {
+		t.Errorf("thresholds[e2e_scheduling] = %v, want %v", got, want)
+	}
+}
+
+func TestPhaseThresholdsFromParamsRejectsWrongType(t *testing.T) {
+	if _, err := phaseThresholdsFromParams(map[string]interface{}{"phaseThresholds": "not-a-map"}); err == nil {
+		t.Errorf("phaseThresholdsFromParams() succeeded, want error for non-map value")
+	}
+}
+
+func TestPhaseThresholdsFromParamsRejectsInvalidDuration(t *testing.T) {
+	params := map[string]interface{}{"phaseThresholds": map[string]interface{}{"binding": "not-a-duration"}}
+	if _, err := phaseThresholdsFromParams(params); err == nil {
+		t.Errorf("phaseThresholdsFromParams() succeeded, want error for invalid duration")
+	}
+}
+
+func TestFormatQuantile(t *testing.T) {
+	if got, want := formatQuantile(0.99), "0.99"; got != want {
+		t.Errorf("formatQuantile(0.99) = %q, want %q", got, want)
+	}
+}
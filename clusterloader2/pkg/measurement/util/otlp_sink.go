@@ -0,0 +1,92 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// OTLPSink exports TimeSeries as OpenTelemetry gauge metrics over OTLP, so
+// results can flow into any generic observability backend that speaks OTLP,
+// not just Prometheus-compatible ones.
+type OTLPSink struct {
+	exporter *otlpmetrichttp.Exporter
+}
+
+// NewOTLPSink creates an OTLPSink talking to cfg.Endpoint.
+func NewOTLPSink(ctx context.Context, cfg PrometheusConfig) (*OTLPSink, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("otlp sink requires a non-empty endpoint")
+	}
+	// Reuse the same bearer/basic-auth/tenant/TLS-aware transport used by
+	// PrometheusClient and RemoteWriteSink, rather than hand-rolling a partial
+	// headers/TLS option set that silently drops BearerTokenFile and
+	// BasicAuthUsername/Password.
+	rt, err := roundTripperFromConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("building otlp http client: %v", err)
+	}
+	opts := []otlpmetrichttp.Option{
+		otlpmetrichttp.WithEndpoint(cfg.Endpoint),
+		otlpmetrichttp.WithHTTPClient(&http.Client{Transport: rt}),
+	}
+	exporter, err := otlpmetrichttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("creating otlp exporter for %q: %v", cfg.Endpoint, err)
+	}
+	return &OTLPSink{exporter: exporter}, nil
+}
+
+// Push exports series as a single batch of OTLP gauge data points.
+func (o *OTLPSink) Push(ctx context.Context, series []TimeSeries) error {
+	points := make([]metricdata.DataPoint[float64], 0, len(series))
+	for _, ts := range series {
+		points = append(points, metricdata.DataPoint[float64]{
+			Attributes: attributeSetFromLabels(ts.Labels),
+			Time:       ts.Timestamp,
+			Value:      ts.Value,
+		})
+	}
+	rm := &metricdata.ResourceMetrics{
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Metrics: []metricdata.Metrics{{
+				Name: "clusterloader2_measurement",
+				Data: metricdata.Gauge[float64]{DataPoints: points},
+			}},
+		}},
+	}
+	return o.exporter.Export(ctx, rm)
+}
+
+// Shutdown flushes and closes the underlying OTLP exporter.
+func (o *OTLPSink) Shutdown(ctx context.Context) error {
+	return o.exporter.Shutdown(ctx)
+}
+
+func attributeSetFromLabels(labels map[string]string) attribute.Set {
+	kvs := make([]attribute.KeyValue, 0, len(labels))
+	for k, v := range labels {
+		kvs = append(kvs, attribute.String(k, v))
+	}
+	return attribute.NewSet(kvs...)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestNewOTLPSinkRequiresEndpoint(t *testing.T) {
+	if _, err := NewOTLPSink(context.Background(), PrometheusConfig{}); err == nil {
+		t.Errorf("NewOTLPSink() with empty endpoint succeeded, want error")
+	}
+}
+
+// TestNewOTLPSinkWiresAuthAndTLSIntoTransport asserts that NewOTLPSink reuses
+// roundTripperFromConfig, the same bearer/basic-auth/tenant/TLS wiring used by
+// PrometheusClient and RemoteWriteSink, instead of only forwarding Tenant and
+// TLSInsecureSkipVerify.
+func TestNewOTLPSinkWiresAuthAndTLSIntoTransport(t *testing.T) {
+	var gotAuth, gotTenant string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotTenant = r.Header.Get("X-Scope-OrgID")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sink, err := NewOTLPSink(context.Background(), PrometheusConfig{
+		Endpoint:              strings.TrimPrefix(server.URL, "https://"),
+		TLSInsecureSkipVerify: true,
+		BasicAuthUsername:     "user",
+		BasicAuthPassword:     "pass",
+		Tenant:                "team-a",
+	})
+	if err != nil {
+		t.Fatalf("NewOTLPSink: %v", err)
+	}
+	defer sink.Shutdown(context.Background())
+
+	if err := sink.Push(context.Background(), []TimeSeries{{
+		Labels:    map[string]string{"resource": "pods"},
+		Value:     1,
+		Timestamp: time.Now(),
+	}}); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	if gotAuth != "Basic dXNlcjpwYXNz" {
+		t.Errorf("Authorization header = %q, want basic auth header", gotAuth)
+	}
+	if gotTenant != "team-a" {
+		t.Errorf("X-Scope-OrgID header = %q, want %q", gotTenant, "team-a")
+	}
+}
+
+func TestAttributeSetFromLabels(t *testing.T) {
+	set := attributeSetFromLabels(map[string]string{"resource": "pods", "verb": "LIST"})
+	if set.Len() != 2 {
+		t.Fatalf("attribute set has %d entries, want 2", set.Len())
+	}
+	for _, kv := range []struct{ key, value string }{{"resource", "pods"}, {"verb", "LIST"}} {
+		v, ok := set.Value(attribute.Key(kv.key))
+		if !ok || v.AsString() != kv.value {
+			t.Errorf("attribute %q = %v (ok=%v), want %q", kv.key, v, ok, kv.value)
+		}
+	}
+}
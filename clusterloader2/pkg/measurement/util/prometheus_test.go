@@ -0,0 +1,142 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+)
+
+// recordingRoundTripper captures the last request it saw, so tests can assert
+// on the headers authRoundTripper injected.
+type recordingRoundTripper struct {
+	lastReq *http.Request
+}
+
+func (r *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	r.lastReq = req
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+}
+
+func TestAuthRoundTripper(t *testing.T) {
+	tokenFile, err := ioutil.TempFile("", "bearer-token")
+	if err != nil {
+		t.Fatalf("creating temp token file: %v", err)
+	}
+	defer os.Remove(tokenFile.Name())
+	if _, err := tokenFile.WriteString("my-token\n"); err != nil {
+		t.Fatalf("writing temp token file: %v", err)
+	}
+	tokenFile.Close()
+
+	tests := []struct {
+		name       string
+		cfg        PrometheusConfig
+		wantAuth   string
+		wantTenant string
+	}{
+		{
+			name:     "bearer token file",
+			cfg:      PrometheusConfig{BearerTokenFile: tokenFile.Name()},
+			wantAuth: "Bearer my-token",
+		},
+		{
+			name:     "basic auth",
+			cfg:      PrometheusConfig{BasicAuthUsername: "user", BasicAuthPassword: "pass"},
+			wantAuth: "Basic dXNlcjpwYXNz",
+		},
+		{
+			name:       "tenant header",
+			cfg:        PrometheusConfig{Tenant: "team-a"},
+			wantTenant: "team-a",
+		},
+		{
+			name: "no auth configured",
+			cfg:  PrometheusConfig{},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			next := &recordingRoundTripper{}
+			rt := &authRoundTripper{cfg: tc.cfg, next: next}
+			req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			if err != nil {
+				t.Fatalf("building request: %v", err)
+			}
+			if _, err := rt.RoundTrip(req); err != nil {
+				t.Fatalf("RoundTrip: %v", err)
+			}
+			if got := next.lastReq.Header.Get("Authorization"); got != tc.wantAuth {
+				t.Errorf("Authorization header = %q, want %q", got, tc.wantAuth)
+			}
+			if got := next.lastReq.Header.Get("X-Scope-OrgID"); got != tc.wantTenant {
+				t.Errorf("X-Scope-OrgID header = %q, want %q", got, tc.wantTenant)
+			}
+		})
+	}
+}
+
+func TestRoundTripperFromConfigTLSInsecureSkipVerify(t *testing.T) {
+	rt, err := roundTripperFromConfig(PrometheusConfig{TLSInsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("roundTripperFromConfig: %v", err)
+	}
+	auth, ok := rt.(*authRoundTripper)
+	if !ok {
+		t.Fatalf("roundTripperFromConfig returned %T, want *authRoundTripper", rt)
+	}
+	transport, ok := auth.next.(*http.Transport)
+	if !ok {
+		t.Fatalf("inner round tripper is %T, want *http.Transport", auth.next)
+	}
+	if transport.TLSClientConfig == nil || !transport.TLSClientConfig.InsecureSkipVerify {
+		t.Errorf("expected TLSClientConfig.InsecureSkipVerify to be true")
+	}
+}
+
+func TestPrometheusConfigFromParamsWithPrefix(t *testing.T) {
+	params := map[string]interface{}{
+		"resultsRemoteWriteEndpoint":              "https://remote-write.example.com",
+		"resultsRemoteWriteTLSInsecureSkipVerify": true,
+		"resultsRemoteWriteTenant":                "team-a",
+	}
+	cfg, err := PrometheusConfigFromParamsWithPrefix(params, "resultsRemoteWrite")
+	if err != nil {
+		t.Fatalf("PrometheusConfigFromParamsWithPrefix: %v", err)
+	}
+	want := PrometheusConfig{
+		Endpoint:              "https://remote-write.example.com",
+		TLSInsecureSkipVerify: true,
+		Tenant:                "team-a",
+	}
+	if cfg != want {
+		t.Errorf("got %+v, want %+v", cfg, want)
+	}
+}
+
+func TestPrometheusConfigFromParamsWithPrefixDefaultsEmpty(t *testing.T) {
+	cfg, err := PrometheusConfigFromParamsWithPrefix(map[string]interface{}{}, "prometheus")
+	if err != nil {
+		t.Fatalf("PrometheusConfigFromParamsWithPrefix: %v", err)
+	}
+	if cfg.Endpoint != "" {
+		t.Errorf("Endpoint = %q, want empty so NewPrometheusClient falls back to the apiserver proxy", cfg.Endpoint)
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// TimeSeries is a single labeled sample, ready to be shipped to a long-term
+// storage backend. Measurements build these from their in-memory results
+// (e.g. one per (resource,subresource,verb,scope,quantile) tuple) and hand
+// them to a TimeSeriesSink at the end of the test.
+type TimeSeries struct {
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
+}
+
+// TimeSeriesSink pushes a batch of TimeSeries to a long-term storage backend.
+// Measurements shouldn't need to know which backend is configured; they just
+// call Push with their results.
+type TimeSeriesSink interface {
+	Push(ctx context.Context, series []TimeSeries) error
+}
+
+// RemoteWriteSink pushes TimeSeries to a Prometheus remote_write endpoint. It
+// reuses the same auth/tenant plumbing as PrometheusClient, so a single
+// PrometheusConfig block can describe both where a measurement reads live
+// metrics from and where it writes its results to for trend analysis.
+type RemoteWriteSink struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewRemoteWriteSink creates a RemoteWriteSink that pushes to cfg.Endpoint.
+func NewRemoteWriteSink(cfg PrometheusConfig) (*RemoteWriteSink, error) {
+	if cfg.Endpoint == "" {
+		return nil, fmt.Errorf("remote_write sink requires a non-empty endpoint")
+	}
+	rt, err := roundTripperFromConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("building remote_write round tripper: %v", err)
+	}
+	return &RemoteWriteSink{endpoint: cfg.Endpoint, client: &http.Client{Transport: rt}}, nil
+}
+
+// Push snappy-compresses and POSTs series to the configured remote_write endpoint.
+func (s *RemoteWriteSink) Push(ctx context.Context, series []TimeSeries) error {
+	req := &prompb.WriteRequest{Timeseries: make([]prompb.TimeSeries, 0, len(series))}
+	for _, ts := range series {
+		labels := make([]prompb.Label, 0, len(ts.Labels))
+		for name, value := range ts.Labels {
+			labels = append(labels, prompb.Label{Name: name, Value: value})
+		}
+		req.Timeseries = append(req.Timeseries, prompb.TimeSeries{
+			Labels:  labels,
+			Samples: []prompb.Sample{{Value: ts.Value, Timestamp: ts.Timestamp.UnixNano() / int64(time.Millisecond)}},
+		})
+	}
+
+	data, err := proto.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("marshaling remote_write request: %v", err)
+	}
+	compressed := snappy.Encode(nil, data)
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(compressed))
+	if err != nil {
+		return fmt.Errorf("building remote_write request: %v", err)
+	}
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("pushing to remote_write endpoint %q: %v", s.endpoint, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("remote_write endpoint %q returned %s", s.endpoint, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+/*
+Copyright 2020 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package util
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+func TestNewRemoteWriteSinkRequiresEndpoint(t *testing.T) {
+	if _, err := NewRemoteWriteSink(PrometheusConfig{}); err == nil {
+		t.Errorf("NewRemoteWriteSink() with empty endpoint succeeded, want error")
+	}
+}
+
+func TestRemoteWriteSinkPush(t *testing.T) {
+	var gotReq *prompb.WriteRequest
+	var gotHeaders http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeaders = r.Header.Clone()
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatalf("reading request body: %v", err)
+		}
+		decompressed, err := snappy.Decode(nil, body)
+		if err != nil {
+			t.Fatalf("snappy decoding request body: %v", err)
+		}
+		gotReq = &prompb.WriteRequest{}
+		if err := proto.Unmarshal(decompressed, gotReq); err != nil {
+			t.Fatalf("unmarshaling WriteRequest: %v", err)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sink, err := NewRemoteWriteSink(PrometheusConfig{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("NewRemoteWriteSink: %v", err)
+	}
+
+	ts := time.Unix(1234, 0)
+	series := []TimeSeries{{
+		Labels:    map[string]string{"__name__": "clusterloader2_api_call_count", "resource": "pods"},
+		Value:     42,
+		Timestamp: ts,
+	}}
+	if err := sink.Push(context.Background(), series); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	if gotHeaders.Get("Content-Encoding") != "snappy" {
+		t.Errorf("Content-Encoding = %q, want snappy", gotHeaders.Get("Content-Encoding"))
+	}
+	if len(gotReq.Timeseries) != 1 {
+		t.Fatalf("got %d timeseries, want 1", len(gotReq.Timeseries))
+	}
+	got := gotReq.Timeseries[0]
+	if len(got.Samples) != 1 || got.Samples[0].Value != 42 {
+		t.Errorf("samples = %v, want a single sample with value 42", got.Samples)
+	}
+	if got.Samples[0].Timestamp != ts.UnixNano()/int64(time.Millisecond) {
+		t.Errorf("sample timestamp = %d, want %d", got.Samples[0].Timestamp, ts.UnixNano()/int64(time.Millisecond))
+	}
+	labels := map[string]string{}
+	for _, l := range got.Labels {
+		labels[l.Name] = l.Value
+	}
+	if labels["resource"] != "pods" {
+		t.Errorf("labels[resource] = %q, want %q", labels["resource"], "pods")
+	}
+}
+
+func TestRemoteWriteSinkPushNon2xx(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	sink, err := NewRemoteWriteSink(PrometheusConfig{Endpoint: server.URL})
+	if err != nil {
+		t.Fatalf("NewRemoteWriteSink: %v", err)
+	}
+	if err := sink.Push(context.Background(), []TimeSeries{{Labels: map[string]string{"a": "b"}, Timestamp: time.Now()}}); err == nil {
+		t.Errorf("Push() succeeded against a 500 response, want error")
+	}
+}